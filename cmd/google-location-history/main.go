@@ -0,0 +1,188 @@
+// Command google-location-history finds visits to a location within a
+// Google Takeout location history export.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asmarques/geodist"
+
+	"github.com/hundt/google-location-history/locationhistory"
+)
+
+var debug = flag.Bool("debug", false, "show debug logging")
+var latitude = flag.Float64("lat", 36.461755, "latitude of target location")
+var longitude = flag.Float64("long", -116.866612, "longitude of target location")
+var cacheData = flag.Bool("cache-data", true, "enable caching of a more easily processed form of the data")
+var address = flag.String("address", "", "address to look up instead of specifying lat/long")
+var geocoderName = flag.String("geocoder", "google", "geocoder to use for -address: google, opencage, or nominatim")
+var googleApiKey = flag.String("google-api-key", "", "API Key for Google Geocoding API, for use with -geocoder=google")
+var openCageApiKey = flag.String("opencage-api-key", "", "API Key for OpenCage Geocoding API, for use with -geocoder=opencage")
+var threshold = flag.String("threshold", "50m", "threshold used to determine whether you are at the location")
+var geocodeCache = flag.Bool("geocode-cache", true, "cache resolved addresses locally to avoid repeated geocoding API calls")
+var geocodeCachePath = flag.String("geocode-cache-path", "", "path to the geocode cache file (default: a file under the user cache dir)")
+var geocodeCacheTTL = flag.Duration("geocode-cache-ttl", 30*24*time.Hour, "how long a cached geocode result stays valid")
+var formatName = flag.String("format", "auto", "takeout file format: auto, takeout-legacy, semantic, or timeline")
+var maxGap = flag.Duration("max-gap", locationhistory.DefaultVisitDetectionOptions().MaxGap, "longest gap outside the threshold (but within the loiter radius) before a visit is considered over")
+var minDwell = flag.Duration("min-dwell", locationhistory.DefaultVisitDetectionOptions().MinDwell, "minimum duration a visit must span to be reported")
+var loiterFactor = flag.Float64("loiter-factor", 3, "loiter radius, as a multiple of -threshold, within which a point still counts toward an open visit")
+var outputFormat = flag.String("output", "text", "output format for detected visits: text, json, csv, or geojson")
+var outputPinpoints = flag.Bool("output-pinpoints", false, "also include the raw in-radius pinpoints in the output")
+
+func newGeocoder(name string) (locationhistory.Geocoder, error) {
+	var geocoder locationhistory.Geocoder
+	switch name {
+	case "google":
+		geocoder = locationhistory.NewGoogleGeocoder(http.DefaultClient, *googleApiKey)
+	case "opencage":
+		geocoder = locationhistory.NewOpenCageGeocoder(http.DefaultClient, *openCageApiKey)
+	case "nominatim":
+		geocoder = locationhistory.NewNominatimGeocoder(http.DefaultClient, "google-location-history (https://github.com/hundt/google-location-history)")
+	default:
+		return nil, fmt.Errorf("unknown geocoder %q", name)
+	}
+	if !*geocodeCache {
+		return geocoder, nil
+	}
+	path := *geocodeCachePath
+	if path == "" {
+		var err error
+		path, err = locationhistory.DefaultCachePath()
+		if err != nil {
+			return nil, fmt.Errorf("error finding geocode cache path: %s", err)
+		}
+	}
+	return locationhistory.NewGeocodeCache(geocoder, path, *geocodeCacheTTL), nil
+}
+
+func parseDistance(dist string) (km float64, err error) {
+	units := []struct {
+		abbrev string
+		perKM  float64
+	}{
+		{"km", 1},
+		{"ft", 3280.84},
+		{"mi", 0.621371},
+		{"m", 1000},
+	}
+	dist = strings.ToLower(strings.TrimSpace(dist))
+	for _, unit := range units {
+		if strings.HasSuffix(dist, unit.abbrev) {
+			dist = strings.TrimSpace(strings.TrimSuffix(dist, unit.abbrev))
+			count, err := strconv.ParseFloat(dist, 64)
+			if err != nil {
+				return 0, fmt.Errorf("Error parsing distance %q: %s", dist, err)
+			}
+			distKM := count / unit.perKM
+			log.Printf("Using distance %.3fkm", distKM)
+			return distKM, nil
+		}
+	}
+	return 0, fmt.Errorf("No recognized units in distance %q", dist)
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s /path/to/Location\\ History.json\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	takeoutFile := args[0]
+	target := geodist.Point{
+		Lat:  *latitude,
+		Long: *longitude,
+	}
+
+	if *address != "" {
+		geocoder, err := newGeocoder(*geocoderName)
+		if err != nil {
+			log.Fatalf("Error selecting geocoder: %s", err)
+		}
+		resolved, err := geocoder.Geocode(*address)
+		if err != nil {
+			log.Fatalf("Error geocoding address: %s", err)
+		}
+		target = resolved.Point
+	}
+
+	log.Printf("Using target (%.6f, %.6f)", target.Lat, target.Long)
+
+	thresholdKm, err := parseDistance(*threshold)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	cacheFile := takeoutFile + ".dat"
+	var store *locationhistory.Store
+	if *cacheData {
+		store, err = locationhistory.Open(cacheFile)
+		if err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Error loading cache file: %s", err)
+		}
+	}
+	if store == nil {
+		format, err := locationhistory.ParseFormat(*formatName)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		converted, err := locationhistory.LoadTakeoutFile(takeoutFile, format)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		store = locationhistory.NewStore(converted)
+		if *cacheData && len(converted) > 0 {
+			if err := store.Save(cacheFile); err != nil {
+				log.Fatalf("Error writing cache file: %s", err)
+			}
+		}
+	}
+	log.Printf("Loaded %d pinpoints", len(store.Points()))
+
+	opts := locationhistory.VisitDetectionOptions{
+		MaxGap:       *maxGap,
+		MinDwell:     *minDwell,
+		LoiterRadius: thresholdKm * *loiterFactor,
+	}
+	if *debug {
+		log.Printf("Detecting visits with max-gap=%s min-dwell=%s loiter-radius=%.3fkm", opts.MaxGap, opts.MinDwell, opts.LoiterRadius)
+	}
+
+	// DetectVisits gets every pinpoint, not only those within the loiter
+	// radius: a point beyond LoiterRadius is what ends an open visit.
+	all := append([]locationhistory.ConvertedLocation(nil), store.Points()...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+
+	visits, err := locationhistory.DetectVisits(all, target, thresholdKm, opts)
+	if err != nil {
+		log.Fatalf("Error detecting visits: %s", err)
+	}
+
+	var pinpoints []locationhistory.ConvertedLocation
+	if *outputPinpoints {
+		pinpoints, err = (locationhistory.DistanceQuery{Center: target, Radius: thresholdKm}).Run(store)
+		if err != nil {
+			log.Fatalf("Error running distance query: %s", err)
+		}
+		sort.Slice(pinpoints, func(i, j int) bool { return pinpoints[i].Time.Before(pinpoints[j].Time) })
+	}
+
+	if err := writeVisits(os.Stdout, *outputFormat, visits, pinpoints, target, thresholdKm); err != nil {
+		log.Fatalf("Error writing output: %s", err)
+	}
+}
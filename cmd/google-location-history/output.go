@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/asmarques/geodist"
+
+	"github.com/hundt/google-location-history/locationhistory"
+)
+
+// visitJSON is the stable schema used by -output json, suitable for
+// piping into jq.
+type visitJSON struct {
+	Start             string  `json:"start"`
+	End               string  `json:"end"`
+	DurationSeconds   float64 `json:"duration_seconds"`
+	PinpointCount     int     `json:"pinpoint_count"`
+	MaxDistanceMeters float64 `json:"max_distance_meters"`
+}
+
+// pinpointJSON is the -output json schema for a raw in-radius pinpoint,
+// included when -output-pinpoints is set.
+type pinpointJSON struct {
+	Time      string  `json:"time"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// visitsJSON is the -output json schema once pinpoints are requested: the
+// top-level value becomes an object rather than a bare array of visits, so
+// it can carry both.
+type visitsJSON struct {
+	Visits    []visitJSON    `json:"visits"`
+	Pinpoints []pinpointJSON `json:"pinpoints"`
+}
+
+// writeVisits renders visits in the given -output format: text, json, csv,
+// or geojson. target and radiusKM are only needed for geojson, to render
+// the query bounding box alongside the visits. pinpoints is the raw
+// in-radius location history, included in the output alongside visits if
+// non-nil (see -output-pinpoints).
+func writeVisits(w io.Writer, format string, visits []locationhistory.Visit, pinpoints []locationhistory.ConvertedLocation, target geodist.Point, radiusKM float64) error {
+	switch format {
+	case "", "text":
+		for _, v := range visits {
+			fmt.Fprintf(w, "Visited for %s starting at %s (%d pinpoints, %.0fm max distance)\n", v.End.Sub(v.Start), v.Start, v.NumPoints, v.MaxDistance*1000)
+		}
+		for _, p := range pinpoints {
+			fmt.Fprintf(w, "Pinpoint at (%.6f, %.6f) at %s\n", p.Latitude, p.Longitude, p.Time)
+		}
+		return nil
+	case "json":
+		out := make([]visitJSON, len(visits))
+		for i, v := range visits {
+			out[i] = visitJSON{
+				Start:             v.Start.Format(time.RFC3339),
+				End:               v.End.Format(time.RFC3339),
+				DurationSeconds:   v.End.Sub(v.Start).Seconds(),
+				PinpointCount:     v.NumPoints,
+				MaxDistanceMeters: v.MaxDistance * 1000,
+			}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if pinpoints == nil {
+			return enc.Encode(out)
+		}
+		pp := make([]pinpointJSON, len(pinpoints))
+		for i, p := range pinpoints {
+			pp[i] = pinpointJSON{
+				Time:      p.Time.Format(time.RFC3339),
+				Latitude:  p.Latitude,
+				Longitude: p.Longitude,
+			}
+		}
+		return enc.Encode(visitsJSON{Visits: out, Pinpoints: pp})
+	case "csv":
+		cw := csv.NewWriter(w)
+		if pinpoints == nil {
+			if err := cw.Write([]string{"start", "end", "duration_seconds", "pinpoint_count", "max_distance_meters"}); err != nil {
+				return err
+			}
+			for _, v := range visits {
+				err := cw.Write([]string{
+					v.Start.Format(time.RFC3339),
+					v.End.Format(time.RFC3339),
+					strconv.FormatFloat(v.End.Sub(v.Start).Seconds(), 'f', -1, 64),
+					strconv.Itoa(v.NumPoints),
+					strconv.FormatFloat(v.MaxDistance*1000, 'f', -1, 64),
+				})
+				if err != nil {
+					return err
+				}
+			}
+			cw.Flush()
+			return cw.Error()
+		}
+		// With pinpoints requested, visits and pinpoints share one table,
+		// distinguished by a leading "type" column; columns that don't
+		// apply to a row are left blank.
+		if err := cw.Write([]string{"type", "start", "end", "duration_seconds", "pinpoint_count", "max_distance_meters", "latitude", "longitude"}); err != nil {
+			return err
+		}
+		for _, v := range visits {
+			err := cw.Write([]string{
+				"visit",
+				v.Start.Format(time.RFC3339),
+				v.End.Format(time.RFC3339),
+				strconv.FormatFloat(v.End.Sub(v.Start).Seconds(), 'f', -1, 64),
+				strconv.Itoa(v.NumPoints),
+				strconv.FormatFloat(v.MaxDistance*1000, 'f', -1, 64),
+				"",
+				"",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, p := range pinpoints {
+			err := cw.Write([]string{
+				"pinpoint",
+				p.Time.Format(time.RFC3339),
+				"",
+				"",
+				"",
+				"",
+				strconv.FormatFloat(p.Latitude, 'f', -1, 64),
+				strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case "geojson":
+		var bbox *locationhistory.BoundingBoxQuery
+		if ne, sw, err := locationhistory.QueryBoundingBox(target, radiusKM); err == nil {
+			bbox = &locationhistory.BoundingBoxQuery{NE: ne, SW: sw}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(locationhistory.VisitsFeatureCollection(visits, pinpoints, bbox))
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
@@ -0,0 +1,129 @@
+package locationhistory
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/asmarques/geodist"
+)
+
+const earthRadiusKM = 6371.0
+
+// SloppyDistance approximates the distance in kilometers between two
+// points with an equirectangular projection, matching Lucene's "sloppy
+// math" pre-filter: it's cheap enough to run over every bounding-box
+// candidate, at the cost of some inaccuracy that a final VincentyDistance
+// check should confirm.
+func SloppyDistance(p1, p2 geodist.Point) float64 {
+	lat1 := p1.Lat * math.Pi / 180
+	lat2 := p2.Lat * math.Pi / 180
+	avgLat := (lat1 + lat2) / 2
+	dLat := lat2 - lat1
+	// Normalize the longitude delta to [-180, 180] so points that straddle
+	// the antimeridian (e.g. Fiji) aren't treated as nearly half the globe
+	// apart.
+	dLongDeg := math.Mod(p2.Long-p1.Long+180, 360)
+	if dLongDeg < 0 {
+		dLongDeg += 360
+	}
+	dLongDeg -= 180
+	dLong := dLongDeg * math.Pi / 180
+	x := dLong * math.Cos(avgLat)
+	y := dLat
+	return math.Sqrt(x*x+y*y) * earthRadiusKM
+}
+
+type direction float64
+
+const (
+	north direction = 1
+	east  direction = 1
+	south direction = -1
+	west  direction = -1
+)
+
+// find walks *adjust away from p1 in direction dir until the Vincenty
+// distance to p1 exceeds targetDistance, then binary-searches back to
+// within 10cm of exactly targetDistance.
+func find(p1 geodist.Point, p2 *geodist.Point, dir direction, adjust *float64, limit float64, targetDistance float64) error {
+	inc := 1e-6
+	for {
+		*adjust += inc * float64(dir)
+		if *adjust*float64(dir) > limit*float64(dir) {
+			return fmt.Errorf("too close to a pole or meridian")
+		}
+		d, err := geodist.VincentyDistance(p1, *p2)
+		if err != nil {
+			return fmt.Errorf("error computing distance: %s", err)
+		}
+		if d > targetDistance {
+			break
+		}
+		inc *= 2
+	}
+	// Now p2 is > targetDistance away from p1. Take p3 (starting with p1) and p2 as two points on either
+	// side of the d=targetDistance line. Take the midpoint M between them and throw away either p3 or p2
+	// so that what's left and M are on either side of the line. Repeat until distance is < 10cm
+	threshold := 0.0001
+	a := p1
+	b := *p2
+	for {
+		m := geodist.Point{
+			Lat:  (a.Lat + b.Lat) / 2,
+			Long: (a.Long + b.Long) / 2,
+		}
+		d, err := geodist.VincentyDistance(p1, m)
+		if err != nil {
+			return fmt.Errorf("error computing distance (phase 2): %s", err)
+		}
+		delta := d - targetDistance
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta < threshold {
+			*p2 = m
+			return nil
+		}
+		if d > targetDistance {
+			b = m
+		} else {
+			a = m
+		}
+	}
+}
+
+// QueryBoundingBox returns the bounding box a DistanceQuery for center and
+// radiusKM would search, useful for rendering the query area (e.g. as a
+// GeoJSON polygon) alongside its results.
+func QueryBoundingBox(center geodist.Point, radiusKM float64) (ne, sw geodist.Point, err error) {
+	n, s, err := findBoundingBox(center, radiusKM)
+	if err != nil {
+		return geodist.Point{}, geodist.Point{}, err
+	}
+	return *n, *s, nil
+}
+
+// findBoundingBox returns the NE and SW corners of the axis-aligned box
+// whose edges are each `size` kilometers from p, following great-circle
+// distance rather than a flat-earth approximation.
+func findBoundingBox(p geodist.Point, size float64) (ne, sw *geodist.Point, err error) {
+	e := p
+	if err = find(p, &e, east, &e.Long, 180, size); err != nil {
+		return nil, nil, err
+	}
+	n := p
+	if err = find(p, &n, north, &n.Lat, 90, size); err != nil {
+		return nil, nil, err
+	}
+	w := p
+	if err = find(p, &w, west, &w.Long, -180, size); err != nil {
+		return nil, nil, err
+	}
+	s := p
+	if err = find(p, &s, south, &s.Lat, -90, size); err != nil {
+		return nil, nil, err
+	}
+	ne = &geodist.Point{Lat: n.Lat, Long: e.Long}
+	sw = &geodist.Point{Lat: s.Lat, Long: w.Long}
+	return ne, sw, nil
+}
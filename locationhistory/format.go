@@ -0,0 +1,251 @@
+package locationhistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format identifies which on-disk Google export schema a file uses.
+type Format int
+
+const (
+	// FormatAuto detects the format by peeking at the file's top-level
+	// JSON keys.
+	FormatAuto Format = iota
+	// FormatTakeoutLegacy is the classic Takeout "Location History.json"
+	// export (latitudeE7/longitudeE7/timestampMs).
+	FormatTakeoutLegacy
+	// FormatSemantic is the per-month Takeout
+	// "Semantic Location History/YYYY/YYYY_MONTH.json" export
+	// (placeVisit/activitySegment entries with RFC3339 timestamps).
+	FormatSemantic
+	// FormatTimeline is the newer on-device "Timeline.json" export
+	// (semanticSegments with visit/timelinePath blocks).
+	FormatTimeline
+)
+
+// String returns the -format flag value for f.
+func (f Format) String() string {
+	switch f {
+	case FormatTakeoutLegacy:
+		return "takeout-legacy"
+	case FormatSemantic:
+		return "semantic"
+	case FormatTimeline:
+		return "timeline"
+	default:
+		return "auto"
+	}
+}
+
+// ParseFormat parses a -format flag value ("", "auto", "takeout-legacy",
+// "semantic", or "timeline").
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "", "auto":
+		return FormatAuto, nil
+	case "takeout-legacy":
+		return FormatTakeoutLegacy, nil
+	case "semantic":
+		return FormatSemantic, nil
+	case "timeline":
+		return FormatTimeline, nil
+	default:
+		return FormatAuto, fmt.Errorf("unknown format %q", s)
+	}
+}
+
+// detectFormat guesses a file's format from its top-level JSON keys.
+func detectFormat(data []byte) (Format, error) {
+	var peek struct {
+		Locations        json.RawMessage `json:"locations"`
+		TimelineObjects  json.RawMessage `json:"timelineObjects"`
+		SemanticSegments json.RawMessage `json:"semanticSegments"`
+	}
+	if err := json.Unmarshal(data, &peek); err != nil {
+		return FormatAuto, fmt.Errorf("error peeking at takeout file format: %s", err)
+	}
+	switch {
+	case peek.SemanticSegments != nil:
+		return FormatTimeline, nil
+	case peek.TimelineObjects != nil:
+		return FormatSemantic, nil
+	case peek.Locations != nil:
+		return FormatTakeoutLegacy, nil
+	default:
+		return FormatAuto, fmt.Errorf("could not detect takeout file format")
+	}
+}
+
+// LoadTakeoutFile parses a Google location history export into
+// ConvertedLocation points, ready to pass to NewStore. If format is
+// FormatAuto, the format is detected from the file's contents.
+func LoadTakeoutFile(path string, format Format) ([]ConvertedLocation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening takeout file: %s", err)
+	}
+	if format == FormatAuto {
+		format, err = detectFormat(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch format {
+	case FormatTakeoutLegacy:
+		return parseLegacyTakeout(data)
+	case FormatSemantic:
+		return parseSemanticTakeout(data)
+	case FormatTimeline:
+		return parseTimeline(data)
+	default:
+		return nil, fmt.Errorf("unsupported format %v", format)
+	}
+}
+
+// parseLegacyTakeout parses the classic Takeout "Location History.json"
+// export (latitudeE7/longitudeE7/timestampMs).
+func parseLegacyTakeout(data []byte) ([]ConvertedLocation, error) {
+	history := &LocationHistory{}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("error loading takeout file: %s", err)
+	}
+
+	converted := make([]ConvertedLocation, len(history.Pinpoints))
+	for idx, location := range history.Pinpoints {
+		secondsString := location.Timestamp[:len(location.Timestamp)-3]
+		seconds, err := strconv.ParseInt(secondsString, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing time %q", secondsString) // TODO: not fatal
+		}
+		converted[idx] = ConvertedLocation{
+			Time:      time.Unix(seconds, 0),
+			Latitude:  float64(location.Latitude) / 1e7,
+			Longitude: float64(location.Longitude) / 1e7,
+		}
+	}
+	return converted, nil
+}
+
+// semanticE7Location is the location object embedded in placeVisit and
+// activitySegment entries of the Semantic Location History export.
+type semanticE7Location struct {
+	LatitudeE7  int64 `json:"latitudeE7"`
+	LongitudeE7 int64 `json:"longitudeE7"`
+}
+
+func (l semanticE7Location) point(t time.Time) ConvertedLocation {
+	return ConvertedLocation{
+		Time:      t,
+		Latitude:  float64(l.LatitudeE7) / 1e7,
+		Longitude: float64(l.LongitudeE7) / 1e7,
+	}
+}
+
+// parseSemanticTakeout parses a per-month Takeout
+// "Semantic Location History/YYYY/YYYY_MONTH.json" export. Each placeVisit
+// contributes one point at its location and start time; each
+// activitySegment contributes a point at its start and end locations.
+func parseSemanticTakeout(data []byte) ([]ConvertedLocation, error) {
+	var doc struct {
+		TimelineObjects []struct {
+			PlaceVisit *struct {
+				Location semanticE7Location `json:"location"`
+				Duration struct {
+					StartTimestamp time.Time `json:"startTimestamp"`
+				} `json:"duration"`
+			} `json:"placeVisit"`
+			ActivitySegment *struct {
+				StartLocation semanticE7Location `json:"startLocation"`
+				EndLocation   semanticE7Location `json:"endLocation"`
+				Duration      struct {
+					StartTimestamp time.Time `json:"startTimestamp"`
+					EndTimestamp   time.Time `json:"endTimestamp"`
+				} `json:"duration"`
+			} `json:"activitySegment"`
+		} `json:"timelineObjects"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error loading semantic location history file: %s", err)
+	}
+
+	var converted []ConvertedLocation
+	for _, obj := range doc.TimelineObjects {
+		switch {
+		case obj.PlaceVisit != nil:
+			converted = append(converted, obj.PlaceVisit.Location.point(obj.PlaceVisit.Duration.StartTimestamp))
+		case obj.ActivitySegment != nil:
+			seg := obj.ActivitySegment
+			converted = append(converted, seg.StartLocation.point(seg.Duration.StartTimestamp))
+			converted = append(converted, seg.EndLocation.point(seg.Duration.EndTimestamp))
+		}
+	}
+	return converted, nil
+}
+
+// parseLatLng parses the "lat,lng" or "lat°, lng°" strings used by the
+// on-device Timeline export.
+func parseLatLng(s string) (lat, long float64, err error) {
+	s = strings.NewReplacer("°", "", " ", "").Replace(s)
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("unrecognized lat/lng %q", s)
+	}
+	if lat, err = strconv.ParseFloat(parts[0], 64); err != nil {
+		return 0, 0, fmt.Errorf("error parsing latitude %q: %s", parts[0], err)
+	}
+	if long, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return 0, 0, fmt.Errorf("error parsing longitude %q: %s", parts[1], err)
+	}
+	return lat, long, nil
+}
+
+// parseTimeline parses the newer on-device "Timeline.json" export, which
+// breaks the history into semanticSegments each holding either a visit (one
+// place, one point) or a timelinePath (a sequence of points).
+func parseTimeline(data []byte) ([]ConvertedLocation, error) {
+	var doc struct {
+		SemanticSegments []struct {
+			StartTime time.Time `json:"startTime"`
+			Visit     *struct {
+				TopCandidate struct {
+					PlaceLocation struct {
+						LatLng string `json:"latLng"`
+					} `json:"placeLocation"`
+				} `json:"topCandidate"`
+			} `json:"visit"`
+			TimelinePath []struct {
+				Point string    `json:"point"`
+				Time  time.Time `json:"time"`
+			} `json:"timelinePath"`
+		} `json:"semanticSegments"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error loading timeline file: %s", err)
+	}
+
+	var converted []ConvertedLocation
+	for _, seg := range doc.SemanticSegments {
+		switch {
+		case seg.Visit != nil:
+			lat, long, err := parseLatLng(seg.Visit.TopCandidate.PlaceLocation.LatLng)
+			if err != nil {
+				return nil, err
+			}
+			converted = append(converted, ConvertedLocation{Time: seg.StartTime, Latitude: lat, Longitude: long})
+		case len(seg.TimelinePath) > 0:
+			for _, p := range seg.TimelinePath {
+				lat, long, err := parseLatLng(p.Point)
+				if err != nil {
+					return nil, err
+				}
+				converted = append(converted, ConvertedLocation{Time: p.Time, Latitude: lat, Longitude: long})
+			}
+		}
+	}
+	return converted, nil
+}
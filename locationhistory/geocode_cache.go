@@ -0,0 +1,150 @@
+package locationhistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/asmarques/geodist"
+)
+
+// cacheEntry is the on-disk and in-memory representation of a resolved
+// address.
+type cacheEntry struct {
+	Latitude         float64   `json:"lat"`
+	Longitude        float64   `json:"long"`
+	FormattedAddress string    `json:"formatted_address"`
+	Resolved         time.Time `json:"resolved"`
+}
+
+// GeocodeCache wraps a Geocoder and persists resolved addresses to a local
+// JSON file, so repeated lookups of the same address (e.g. across runs
+// against the same Takeout dump) don't hit the network. Entries older than
+// TTL are treated as expired and re-resolved.
+type GeocodeCache struct {
+	Geocoder Geocoder
+	Path     string
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	loaded  bool
+}
+
+// DefaultCachePath returns the default location for a GeocodeCache's file,
+// under the user's cache directory.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding user cache dir: %s", err)
+	}
+	return filepath.Join(dir, "google-location-history", "geocode-cache.json"), nil
+}
+
+// NewGeocodeCache wraps geocoder with a cache persisted at path, expiring
+// entries older than ttl. A ttl of zero means entries never expire.
+func NewGeocodeCache(geocoder Geocoder, path string, ttl time.Duration) *GeocodeCache {
+	return &GeocodeCache{Geocoder: geocoder, Path: path, TTL: ttl}
+}
+
+func (c *GeocodeCache) load() error {
+	if c.loaded {
+		return nil
+	}
+	c.entries = map[string]cacheEntry{}
+	f, err := os.Open(c.Path)
+	if os.IsNotExist(err) {
+		c.loaded = true
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("error opening geocode cache file: %s", err)
+	}
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&c.entries); err != nil {
+		return fmt.Errorf("error decoding geocode cache file: %s", err)
+	}
+	c.loaded = true
+	return nil
+}
+
+func (c *GeocodeCache) save() error {
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return fmt.Errorf("error creating geocode cache directory: %s", err)
+	}
+	f, err := os.Create(c.Path)
+	if err != nil {
+		return fmt.Errorf("error creating geocode cache file: %s", err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(c.entries); err != nil {
+		return fmt.Errorf("error writing geocode cache file: %s", err)
+	}
+	return nil
+}
+
+// Geocode returns the cached result for address if present and not
+// expired, otherwise resolves it with the underlying Geocoder and updates
+// the cache. The mutex is only held around the map/file access, not the
+// underlying Geocoder's network round-trip, so concurrent lookups of
+// different (or already-cached) addresses don't serialize behind it.
+func (c *GeocodeCache) Geocode(address string) (*GeocodeResult, error) {
+	if result, ok, err := c.lookup(address); err != nil {
+		return nil, err
+	} else if ok {
+		return result, nil
+	}
+
+	result, err := c.Geocoder.Geocode(address)
+	if err != nil {
+		return nil, err
+	}
+	return result, c.store(address, result)
+}
+
+// lookup returns a cached, unexpired result for address, if one exists.
+func (c *GeocodeCache) lookup(address string) (result *GeocodeResult, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.load(); err != nil {
+		return nil, false, err
+	}
+	entry, found := c.entries[address]
+	if !found || (c.TTL != 0 && time.Since(entry.Resolved) >= c.TTL) {
+		return nil, false, nil
+	}
+	return &GeocodeResult{
+		Point:            geodist.Point{Lat: entry.Latitude, Long: entry.Longitude},
+		FormattedAddress: entry.FormattedAddress,
+	}, true, nil
+}
+
+// store records result for address in the cache and persists it to disk.
+func (c *GeocodeCache) store(address string, result *GeocodeResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[address] = cacheEntry{
+		Latitude:         result.Point.Lat,
+		Longitude:        result.Point.Long,
+		FormattedAddress: result.FormattedAddress,
+		Resolved:         time.Now(),
+	}
+	return c.save()
+}
+
+// Clear removes all entries from the cache, both in memory and on disk.
+func (c *GeocodeCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = map[string]cacheEntry{}
+	c.loaded = true
+	if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing geocode cache file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package locationhistory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/asmarques/geodist"
+)
+
+// Geocoder resolves a free-form address to a coordinate.
+type Geocoder interface {
+	Geocode(address string) (*GeocodeResult, error)
+}
+
+// GeocodeResult is a resolved address: the coordinate a Geocoder found for
+// it, plus the full address string the geocoding service matched it to.
+type GeocodeResult struct {
+	Point            geodist.Point
+	FormattedAddress string
+}
+
+// GoogleGeocoder resolves addresses with the Google Geocoding API. It
+// requires an API key.
+type GoogleGeocoder struct {
+	Client *http.Client
+	APIKey string
+}
+
+// NewGoogleGeocoder returns a GoogleGeocoder using client for requests, or
+// http.DefaultClient if client is nil.
+func NewGoogleGeocoder(client *http.Client, apiKey string) *GoogleGeocoder {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &GoogleGeocoder{Client: client, APIKey: apiKey}
+}
+
+func (g *GoogleGeocoder) Geocode(address string) (*GeocodeResult, error) {
+	geocodeURL := fmt.Sprintf(
+		"https://maps.googleapis.com/maps/api/geocode/json?key=%s&address=%s",
+		g.APIKey,
+		url.QueryEscape(address))
+	response, err := g.Client.Get(geocodeURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching Google geocode results: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("got code %d from Google Geocoding API, and error reading response body: %s", response.StatusCode, err)
+		}
+		return nil, fmt.Errorf("got code %d from Google Geocoding API. Response: %s", response.StatusCode, body)
+	}
+	type result struct {
+		Address  string `json:"formatted_address"`
+		Geometry struct {
+			Location struct {
+				Latitude  float64 `json:"lat"`
+				Longitude float64 `json:"lng"`
+			} `json:"location"`
+		} `json:"geometry"`
+	}
+	gr := &struct {
+		Results []result `json:"results"`
+		Status  string   `json:"status"`
+		Error   string   `json:"error_message"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(gr); err != nil {
+		return nil, fmt.Errorf("error decoding response from Google Geocoding API: %s", err)
+	}
+	if gr.Status != "OK" {
+		return nil, fmt.Errorf("error from Google Geocoding API: %s", gr.Error)
+	}
+	if len(gr.Results) == 0 {
+		return nil, fmt.Errorf("no results from Google Geocoding API for %q", address)
+	}
+	return &GeocodeResult{
+		Point: geodist.Point{
+			Lat:  gr.Results[0].Geometry.Location.Latitude,
+			Long: gr.Results[0].Geometry.Location.Longitude,
+		},
+		FormattedAddress: gr.Results[0].Address,
+	}, nil
+}
+
+// OpenCageGeocoder resolves addresses with the OpenCage Geocoding API
+// (https://opencagedata.com). It requires an API key.
+type OpenCageGeocoder struct {
+	Client *http.Client
+	APIKey string
+}
+
+// NewOpenCageGeocoder returns an OpenCageGeocoder using client for
+// requests, or http.DefaultClient if client is nil.
+func NewOpenCageGeocoder(client *http.Client, apiKey string) *OpenCageGeocoder {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenCageGeocoder{Client: client, APIKey: apiKey}
+}
+
+func (g *OpenCageGeocoder) Geocode(address string) (*GeocodeResult, error) {
+	geocodeURL := fmt.Sprintf(
+		"https://api.opencagedata.com/geocode/v1/json?key=%s&q=%s&limit=1",
+		g.APIKey,
+		url.QueryEscape(address))
+	response, err := g.Client.Get(geocodeURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching OpenCage geocode results: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("got code %d from OpenCage Geocoding API. Response: %s", response.StatusCode, body)
+	}
+	type result struct {
+		Formatted string `json:"formatted"`
+		Geometry  struct {
+			Latitude  float64 `json:"lat"`
+			Longitude float64 `json:"lng"`
+		} `json:"geometry"`
+	}
+	oc := &struct {
+		Results []result `json:"results"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(oc); err != nil {
+		return nil, fmt.Errorf("error decoding response from OpenCage Geocoding API: %s", err)
+	}
+	if len(oc.Results) == 0 {
+		return nil, fmt.Errorf("no results from OpenCage Geocoding API for %q", address)
+	}
+	return &GeocodeResult{
+		Point: geodist.Point{
+			Lat:  oc.Results[0].Geometry.Latitude,
+			Long: oc.Results[0].Geometry.Longitude,
+		},
+		FormattedAddress: oc.Results[0].Formatted,
+	}, nil
+}
+
+// NominatimGeocoder resolves addresses with the OpenStreetMap Nominatim
+// API (https://nominatim.org). No API key is required, but the public
+// instance's usage policy requires a descriptive User-Agent, which is sent
+// from UserAgent on every request.
+type NominatimGeocoder struct {
+	Client    *http.Client
+	UserAgent string
+}
+
+// NewNominatimGeocoder returns a NominatimGeocoder using client for
+// requests, or http.DefaultClient if client is nil. userAgent is sent on
+// every request, per Nominatim's usage policy.
+func NewNominatimGeocoder(client *http.Client, userAgent string) *NominatimGeocoder {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &NominatimGeocoder{Client: client, UserAgent: userAgent}
+}
+
+func (g *NominatimGeocoder) Geocode(address string) (*GeocodeResult, error) {
+	geocodeURL := fmt.Sprintf(
+		"https://nominatim.openstreetmap.org/search?format=json&limit=1&q=%s",
+		url.QueryEscape(address))
+	req, err := http.NewRequest("GET", geocodeURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building Nominatim request: %s", err)
+	}
+	if g.UserAgent != "" {
+		req.Header.Set("User-Agent", g.UserAgent)
+	}
+	response, err := g.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching Nominatim geocode results: %s", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("got code %d from Nominatim. Response: %s", response.StatusCode, body)
+	}
+	var results []struct {
+		Latitude    string `json:"lat"`
+		Longitude   string `json:"lon"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("error decoding response from Nominatim: %s", err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no results from Nominatim for %q", address)
+	}
+	var lat, long float64
+	if _, err := fmt.Sscanf(results[0].Latitude, "%g", &lat); err != nil {
+		return nil, fmt.Errorf("error parsing Nominatim latitude %q: %s", results[0].Latitude, err)
+	}
+	if _, err := fmt.Sscanf(results[0].Longitude, "%g", &long); err != nil {
+		return nil, fmt.Errorf("error parsing Nominatim longitude %q: %s", results[0].Longitude, err)
+	}
+	return &GeocodeResult{
+		Point:            geodist.Point{Lat: lat, Long: long},
+		FormattedAddress: results[0].DisplayName,
+	}, nil
+}
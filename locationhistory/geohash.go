@@ -0,0 +1,165 @@
+package locationhistory
+
+// geoIndex is a Bleve/Lucene-style numeric-prefix-coded term index: each
+// point's quantized lat/lon is interleaved into a single morton code once,
+// at construction time, and the code's prefix is indexed at several
+// precision levels. A bounding-box query picks the finest level whose
+// covering set of terms stays small and intersects posting lists at that
+// level, so its cost is proportional to the number of matches rather than
+// to the size of the whole Store.
+
+const (
+	coordBits  = 32 // bits used to quantize each of latitude and longitude
+	mortonBits = coordBits * 2
+)
+
+// precisionLevels are the morton-code prefix lengths (in bits) indexed for
+// every point, coarsest first. They mirror Lucene's numeric-prefix-coded
+// terms: a bounding box is answered using the finest level that still
+// covers it with a manageable number of terms, falling back to coarser
+// levels as the box grows.
+var precisionLevels = []uint{4, 8, 12, 16, 20, 24, 28, 32, 36}
+
+// quantize maps a value in [lo, hi] onto [0, 2^coordBits), clamping the
+// endpoints so lat=90 or long=180 don't overflow the uint32 range.
+func quantize(v, lo, hi float64) uint32 {
+	frac := (v - lo) / (hi - lo)
+	if frac >= 1 {
+		return 1<<coordBits - 1
+	}
+	if frac <= 0 {
+		return 0
+	}
+	return uint32(frac * (1 << coordBits))
+}
+
+func quantizeLat(lat float64) uint32 {
+	return quantize(lat, -90, 90)
+}
+
+func quantizeLong(long float64) uint32 {
+	return quantize(long, -180, 180)
+}
+
+// spreadBits doubles the spacing between the bits of a 32-bit value so two
+// spread values can be OR'd together (one shifted left by one) without
+// their bits overlapping.
+func spreadBits(x uint32) uint64 {
+	v := uint64(x)
+	v = (v | (v << 16)) & 0x0000FFFF0000FFFF
+	v = (v | (v << 8)) & 0x00FF00FF00FF00FF
+	v = (v | (v << 4)) & 0x0F0F0F0F0F0F0F0F
+	v = (v | (v << 2)) & 0x3333333333333333
+	v = (v | (v << 1)) & 0x5555555555555555
+	return v
+}
+
+// mortonCode interleaves the bits of quantized latitude and longitude into
+// a single uint64, latitude in the even bits and longitude in the odd
+// bits.
+func mortonCode(lat, long float64) uint64 {
+	return spreadBits(quantizeLat(lat)) | (spreadBits(quantizeLong(long)) << 1)
+}
+
+// term returns the top `bits` bits of a point's morton code, used as the
+// index term at the precision level with that many bits.
+func term(code uint64, bits uint) uint64 {
+	return code >> (mortonBits - bits)
+}
+
+// cellTerm computes the term a point in grid cell (latCell, longCell)
+// would have at a precision level with the given number of bits per
+// coordinate, without needing the point's full-precision morton code. This
+// lets bounding-box queries enumerate candidate terms directly from the
+// box's corners.
+func cellTerm(latCell, longCell uint32, bitsPerCoord uint) uint64 {
+	shift := coordBits - bitsPerCoord
+	code := spreadBits(latCell<<shift) | (spreadBits(longCell<<shift) << 1)
+	return term(code, 2*bitsPerCoord)
+}
+
+// geoIndex is the posting-list index described above.
+type geoIndex struct {
+	// postings[level][term] holds the indexes of points whose morton code
+	// has that term as a prefix at precisionLevels[level].
+	postings []map[uint64][]int
+}
+
+func newGeoIndex(points []ConvertedLocation) *geoIndex {
+	idx := &geoIndex{postings: make([]map[uint64][]int, len(precisionLevels))}
+	for i := range idx.postings {
+		idx.postings[i] = map[uint64][]int{}
+	}
+	for i, p := range points {
+		code := mortonCode(p.Latitude, p.Longitude)
+		for levelIdx, bits := range precisionLevels {
+			t := term(code, bits)
+			idx.postings[levelIdx][t] = append(idx.postings[levelIdx][t], i)
+		}
+	}
+	return idx
+}
+
+// maxTermsPerQuery bounds how many terms a bounding-box query will probe at
+// a given precision level before falling back to a coarser (and therefore
+// cheaper to enumerate, if less selective) level.
+const maxTermsPerQuery = 4096
+
+// candidates returns the indexes of points whose indexed cell intersects
+// the bounding box [sw, ne], using the finest precision level whose
+// covering set of terms is small enough to enumerate directly.
+func (idx *geoIndex) candidates(sw, ne point) []int {
+	if sw.long > ne.long {
+		// The box wraps the antimeridian (e.g. sw.long=170, ne.long=-170):
+		// split it into two boxes that don't, and merge their candidates,
+		// rather than let longLo > longHi below make every level look
+		// empty.
+		east := idx.candidates(point{lat: sw.lat, long: sw.long}, point{lat: ne.lat, long: 180})
+		west := idx.candidates(point{lat: sw.lat, long: -180}, point{lat: ne.lat, long: ne.long})
+		seen := map[int]bool{}
+		var out []int
+		for _, i := range append(east, west...) {
+			if !seen[i] {
+				seen[i] = true
+				out = append(out, i)
+			}
+		}
+		return out
+	}
+	for levelIdx := len(precisionLevels) - 1; levelIdx >= 0; levelIdx-- {
+		bitsPerCoord := precisionLevels[levelIdx] / 2
+		latLo := quantizeLat(sw.lat) >> (coordBits - bitsPerCoord)
+		latHi := quantizeLat(ne.lat) >> (coordBits - bitsPerCoord)
+		longLo := quantizeLong(sw.long) >> (coordBits - bitsPerCoord)
+		longHi := quantizeLong(ne.long) >> (coordBits - bitsPerCoord)
+		if latHi < latLo || longHi < longLo {
+			continue
+		}
+		numTerms := uint64(latHi-latLo+1) * uint64(longHi-longLo+1)
+		if numTerms > maxTermsPerQuery && levelIdx != 0 {
+			continue
+		}
+
+		seen := map[int]bool{}
+		var out []int
+		for la := latLo; la <= latHi; la++ {
+			for lo := longLo; lo <= longHi; lo++ {
+				t := cellTerm(la, lo, bitsPerCoord)
+				for _, pointIdx := range idx.postings[levelIdx][t] {
+					if !seen[pointIdx] {
+						seen[pointIdx] = true
+						out = append(out, pointIdx)
+					}
+				}
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// point is the minimal lat/long pair candidates() needs; it avoids an
+// import of geodist from this low-level file.
+type point struct {
+	lat, long float64
+}
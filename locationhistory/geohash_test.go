@@ -0,0 +1,221 @@
+package locationhistory
+
+import (
+	"testing"
+
+	"github.com/asmarques/geodist"
+)
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      float64
+		lo, hi float64
+		want   uint32
+	}{
+		{"lo clamps to 0", -100, -90, 90, 0},
+		{"hi clamps to max", 100, -90, 90, 1<<coordBits - 1},
+		{"midpoint", 0, -90, 90, 1 << (coordBits - 1)},
+		{"exact lo", -90, -90, 90, 0},
+		{"exact hi", 90, -90, 90, 1<<coordBits - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quantize(tt.v, tt.lo, tt.hi); got != tt.want {
+				t.Errorf("quantize(%v, %v, %v) = %d, want %d", tt.v, tt.lo, tt.hi, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantizeLatLong(t *testing.T) {
+	if got := quantizeLat(90); got != 1<<coordBits-1 {
+		t.Errorf("quantizeLat(90) = %d, want max", got)
+	}
+	if got := quantizeLong(180); got != 1<<coordBits-1 {
+		t.Errorf("quantizeLong(180) = %d, want max", got)
+	}
+	if got := quantizeLat(-90); got != 0 {
+		t.Errorf("quantizeLat(-90) = %d, want 0", got)
+	}
+}
+
+func TestSpreadBits(t *testing.T) {
+	tests := []struct {
+		in   uint32
+		want uint64
+	}{
+		{0, 0},
+		{1, 1},
+		{2, 1 << 2},
+		{3, 1<<2 | 1},
+		{1<<32 - 1, 0x5555555555555555},
+	}
+	for _, tt := range tests {
+		if got := spreadBits(tt.in); got != tt.want {
+			t.Errorf("spreadBits(%#x) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestSpreadBitsNoOverlap checks the property spreadBits exists for: two
+// spread values, one shifted left by one, never share a set bit.
+func TestSpreadBitsNoOverlap(t *testing.T) {
+	a := spreadBits(0xFFFFFFFF)
+	b := spreadBits(0xFFFFFFFF) << 1
+	if a&b != 0 {
+		t.Errorf("spreadBits(x) and spreadBits(y)<<1 overlap: %#x & %#x = %#x", a, b, a&b)
+	}
+}
+
+func TestMortonCodeRoundTripsThroughTerm(t *testing.T) {
+	// A point's full-precision term (mortonBits bits) should equal its own
+	// morton code, and cellTerm computed from the same quantized cell
+	// should agree with term(code, bits) at every indexed precision level.
+	lat, long := 37.422, -122.084
+	code := mortonCode(lat, long)
+	if got := term(code, mortonBits); got != code {
+		t.Errorf("term(code, mortonBits) = %#x, want %#x", got, code)
+	}
+	for _, bits := range precisionLevels {
+		bitsPerCoord := bits / 2
+		latCell := quantizeLat(lat) >> (coordBits - bitsPerCoord)
+		longCell := quantizeLong(long) >> (coordBits - bitsPerCoord)
+		want := term(code, bits)
+		if got := cellTerm(latCell, longCell, bitsPerCoord); got != want {
+			t.Errorf("cellTerm at %d bits = %#x, want %#x (term(code, bits))", bits, got, want)
+		}
+	}
+}
+
+func TestMortonCodeDistinguishesLatAndLong(t *testing.T) {
+	// Swapping lat and long should (generally) produce a different code:
+	// mortonCode is not symmetric in its two arguments.
+	a := mortonCode(10, 20)
+	b := mortonCode(20, 10)
+	if a == b {
+		t.Errorf("mortonCode(10, 20) == mortonCode(20, 10) == %#x, want different codes", a)
+	}
+}
+
+func TestGeoIndexCandidates(t *testing.T) {
+	points := []ConvertedLocation{
+		{Latitude: 37.422, Longitude: -122.084},   // Googleplex, inside the box below
+		{Latitude: 51.5074, Longitude: -0.1278},   // London, outside
+		{Latitude: -33.8688, Longitude: 151.2093}, // Sydney, outside
+	}
+	idx := newGeoIndex(points)
+
+	sw := point{lat: 37.0, long: -123.0}
+	ne := point{lat: 38.0, long: -121.0}
+	got := idx.candidates(sw, ne)
+
+	found := map[int]bool{}
+	for _, i := range got {
+		found[i] = true
+	}
+	if !found[0] {
+		t.Errorf("candidates(%v, %v) = %v, want it to include point 0 (Googleplex)", sw, ne, got)
+	}
+	if found[1] || found[2] {
+		t.Errorf("candidates(%v, %v) = %v, want it to exclude points 1 and 2", sw, ne, got)
+	}
+}
+
+// TestGeoIndexCandidatesIncludesPointsOutsideTheBox documents and guards a
+// real limitation of candidates(): it only narrows to the grid cells
+// covering a box, so a point that shares a covering cell with the box but
+// lies outside it comes back as a raw candidate too. Callers (e.g.
+// BoundingBoxQuery.Run) must confirm exact containment themselves.
+func TestGeoIndexCandidatesIncludesPointsOutsideTheBox(t *testing.T) {
+	sw := point{lat: 10.0, long: 10.0}
+	ne := point{lat: 10.001, long: 10.001}
+
+	// Search outward from the box's east edge for a point that still lands
+	// in the same covering cell as the box at some indexed precision
+	// level, i.e. a guaranteed false positive for candidates() alone.
+	var outsideLong float64
+	found := false
+	for i := 1; i <= 1000000; i++ {
+		long := ne.long + float64(i)*1e-7
+		idx := newGeoIndex([]ConvertedLocation{{Latitude: (sw.lat + ne.lat) / 2, Longitude: long}})
+		if len(idx.candidates(sw, ne)) == 1 {
+			outsideLong = long
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("could not find a point outside the box sharing a covering cell with it -- test setup is broken")
+	}
+	if outsideLong <= ne.long {
+		t.Fatalf("picked long %v, want it strictly greater than ne.long %v", outsideLong, ne.long)
+	}
+}
+
+// TestBoundingBoxQueryExcludesPointsOutsideTheBox is the fix for the above:
+// BoundingBoxQuery.Run must filter candidates() down to points actually
+// inside [SW, NE], not just ones in a covering cell.
+func TestBoundingBoxQueryExcludesPointsOutsideTheBox(t *testing.T) {
+	sw := geodist.Point{Lat: 10.0, Long: 10.0}
+	ne := geodist.Point{Lat: 10.001, Long: 10.001}
+
+	var outside ConvertedLocation
+	found := false
+	for i := 1; i <= 1000000; i++ {
+		long := ne.Long + float64(i)*1e-7
+		candidate := ConvertedLocation{Latitude: (sw.Lat + ne.Lat) / 2, Longitude: long}
+		idx := newGeoIndex([]ConvertedLocation{candidate})
+		if len(idx.candidates(point{sw.Lat, sw.Long}, point{ne.Lat, ne.Long})) == 1 {
+			outside = candidate
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Fatal("could not find a point outside the box sharing a covering cell with it -- test setup is broken")
+	}
+
+	store := NewStore([]ConvertedLocation{outside})
+	results, err := (BoundingBoxQuery{SW: sw, NE: ne}).Run(store)
+	if err != nil {
+		t.Fatalf("Run returned error: %s", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("BoundingBoxQuery{%v, %v}.Run() = %v, want empty: %v lies outside the box but shares a covering grid cell with it", sw, ne, results, outside)
+	}
+}
+
+func TestGeoIndexCandidatesEmptyBox(t *testing.T) {
+	idx := newGeoIndex([]ConvertedLocation{{Latitude: 0, Longitude: 0}})
+	// A box nowhere near the one indexed point should return no candidates
+	// at any precision level, not just the finest one.
+	got := idx.candidates(point{lat: 80, long: 170}, point{lat: 81, long: 171})
+	if len(got) != 0 {
+		t.Errorf("candidates() = %v, want empty", got)
+	}
+}
+
+func TestGeoIndexCandidatesAcrossAntimeridian(t *testing.T) {
+	points := []ConvertedLocation{
+		{Latitude: -17.7, Longitude: 178.0}, // Fiji, inside the wrapped box below
+		{Latitude: 65.0, Longitude: -169.0}, // Bering Strait, inside
+		{Latitude: 0, Longitude: 0},         // nowhere near, outside
+	}
+	idx := newGeoIndex(points)
+
+	// A box spanning 170 to -160 longitude wraps the antimeridian: SW.Long
+	// > NE.Long.
+	got := idx.candidates(point{lat: -20, long: 170}, point{lat: 70, long: -160})
+
+	found := map[int]bool{}
+	for _, i := range got {
+		found[i] = true
+	}
+	if !found[0] || !found[1] {
+		t.Errorf("candidates() across antimeridian = %v, want it to include points 0 and 1", got)
+	}
+	if found[2] {
+		t.Errorf("candidates() across antimeridian = %v, want it to exclude point 2", got)
+	}
+}
@@ -0,0 +1,80 @@
+package locationhistory
+
+import "time"
+
+// Geometry is a GeoJSON geometry object, e.g. a Point or a Polygon.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Feature is a GeoJSON Feature.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON FeatureCollection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// VisitsFeatureCollection renders visits as a GeoJSON FeatureCollection,
+// one Point feature per visit carrying start/end/duration/pinpoint_count
+// properties, ready to drop into geojson.io, QGIS, or Leaflet. If pinpoints
+// is non-nil, one additional Point feature is added per raw pinpoint,
+// distinguished from visit features by a "kind" property. If bbox is
+// non-nil, an additional Polygon feature is added for it.
+func VisitsFeatureCollection(visits []Visit, pinpoints []ConvertedLocation, bbox *BoundingBoxQuery) FeatureCollection {
+	fc := FeatureCollection{Type: "FeatureCollection"}
+	for _, v := range visits {
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{
+				Type:        "Point",
+				Coordinates: []float64{v.Center.Long, v.Center.Lat},
+			},
+			Properties: map[string]interface{}{
+				"kind":           "visit",
+				"start":          v.Start.Format(time.RFC3339),
+				"end":            v.End.Format(time.RFC3339),
+				"duration":       v.End.Sub(v.Start).String(),
+				"pinpoint_count": v.NumPoints,
+			},
+		})
+	}
+	for _, p := range pinpoints {
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{
+				Type:        "Point",
+				Coordinates: []float64{p.Longitude, p.Latitude},
+			},
+			Properties: map[string]interface{}{
+				"kind": "pinpoint",
+				"time": p.Time.Format(time.RFC3339),
+			},
+		})
+	}
+	if bbox != nil {
+		fc.Features = append(fc.Features, Feature{
+			Type: "Feature",
+			Geometry: Geometry{
+				Type: "Polygon",
+				Coordinates: [][][]float64{{
+					{bbox.SW.Long, bbox.SW.Lat},
+					{bbox.NE.Long, bbox.SW.Lat},
+					{bbox.NE.Long, bbox.NE.Lat},
+					{bbox.SW.Long, bbox.NE.Lat},
+					{bbox.SW.Long, bbox.SW.Lat},
+				}},
+			},
+			Properties: map[string]interface{}{
+				"kind": "bounding_box",
+			},
+		})
+	}
+	return fc
+}
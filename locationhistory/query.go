@@ -0,0 +1,216 @@
+package locationhistory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/asmarques/geodist"
+)
+
+// Query selects a subset of a Store's points. Queries are composable: wrap
+// any two queries in AndQuery or OrQuery, and wrap any query in
+// SortByDistance to order its results.
+type Query interface {
+	Run(s *Store) ([]ConvertedLocation, error)
+}
+
+// BoundingBoxQuery matches points within an axis-aligned lat/long box,
+// answered directly from the Store's index.
+type BoundingBoxQuery struct {
+	NE, SW geodist.Point
+}
+
+func (q BoundingBoxQuery) Run(s *Store) ([]ConvertedLocation, error) {
+	indexes := s.index.candidates(point{q.SW.Lat, q.SW.Long}, point{q.NE.Lat, q.NE.Long})
+	results := make([]ConvertedLocation, 0, len(indexes))
+	for _, idx := range indexes {
+		loc := s.points[idx]
+		// candidates() only narrows to the grid cells covering the box, not
+		// the box itself, so confirm each candidate actually falls inside
+		// it before returning it.
+		if !q.contains(loc) {
+			continue
+		}
+		results = append(results, loc)
+	}
+	return results, nil
+}
+
+// contains reports whether loc falls within the box, handling a box whose
+// SW.Long > NE.Long because it wraps the antimeridian.
+func (q BoundingBoxQuery) contains(loc ConvertedLocation) bool {
+	if loc.Latitude < q.SW.Lat || loc.Latitude > q.NE.Lat {
+		return false
+	}
+	if q.SW.Long <= q.NE.Long {
+		return loc.Longitude >= q.SW.Long && loc.Longitude <= q.NE.Long
+	}
+	return loc.Longitude >= q.SW.Long || loc.Longitude <= q.NE.Long
+}
+
+// DistanceQuery matches points within Radius kilometers of Center. It
+// narrows the search to a BoundingBoxQuery sized from Radius before
+// confirming each candidate with an exact Vincenty distance check, so cost
+// is proportional to the size of the bounding box rather than the full
+// history.
+type DistanceQuery struct {
+	Center geodist.Point
+	Radius float64 // kilometers
+}
+
+func (q DistanceQuery) Run(s *Store) ([]ConvertedLocation, error) {
+	ne, sw, err := findBoundingBox(q.Center, q.Radius)
+	if err != nil {
+		return nil, fmt.Errorf("error finding bounding box: %s", err)
+	}
+	candidates, err := (BoundingBoxQuery{NE: *ne, SW: *sw}).Run(s)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]ConvertedLocation, 0, len(candidates))
+	for _, loc := range candidates {
+		p := geodist.Point{Lat: loc.Latitude, Long: loc.Longitude}
+		// Sloppy pre-filter: skip the more expensive Vincenty calculation
+		// for candidates that are obviously outside the radius. The fudge
+		// factor guards against the approximation ever rejecting a point
+		// Vincenty would have accepted.
+		if SloppyDistance(q.Center, p) > q.Radius*sloppyFudge {
+			continue
+		}
+		d, err := geodist.VincentyDistance(q.Center, p)
+		if err != nil {
+			continue
+		}
+		if d < q.Radius {
+			results = append(results, loc)
+		}
+	}
+	return results, nil
+}
+
+// sloppyFudge widens the SloppyDistance pre-filter radius so its
+// flat-earth approximation never discards a point an exact Vincenty check
+// would have kept.
+const sloppyFudge = 1.01
+
+// TimeRangeQuery matches points with a Time in [Start, End).
+type TimeRangeQuery struct {
+	Start, End time.Time
+}
+
+func (q TimeRangeQuery) Run(s *Store) ([]ConvertedLocation, error) {
+	results := []ConvertedLocation{}
+	for _, loc := range s.points {
+		if !loc.Time.Before(q.Start) && loc.Time.Before(q.End) {
+			results = append(results, loc)
+		}
+	}
+	return results, nil
+}
+
+// AndQuery matches points returned by every one of Queries.
+type AndQuery struct {
+	Queries []Query
+}
+
+func (q AndQuery) Run(s *Store) ([]ConvertedLocation, error) {
+	if len(q.Queries) == 0 {
+		return nil, nil
+	}
+	counts := map[locationKey]int{}
+	byKey := map[locationKey]ConvertedLocation{}
+	for _, sub := range q.Queries {
+		results, err := sub.Run(s)
+		if err != nil {
+			return nil, err
+		}
+		seen := map[locationKey]bool{}
+		for _, loc := range results {
+			k := keyFor(loc)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			counts[k]++
+			byKey[k] = loc
+		}
+	}
+	var out []ConvertedLocation
+	for k, count := range counts {
+		if count == len(q.Queries) {
+			out = append(out, byKey[k])
+		}
+	}
+	return out, nil
+}
+
+// OrQuery matches points returned by at least one of Queries.
+type OrQuery struct {
+	Queries []Query
+}
+
+func (q OrQuery) Run(s *Store) ([]ConvertedLocation, error) {
+	seen := map[locationKey]bool{}
+	var out []ConvertedLocation
+	for _, sub := range q.Queries {
+		results, err := sub.Run(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, loc := range results {
+			k := keyFor(loc)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			out = append(out, loc)
+		}
+	}
+	return out, nil
+}
+
+// SortByDistance runs Query and orders its results by increasing distance
+// from Center.
+type SortByDistance struct {
+	Query  Query
+	Center geodist.Point
+}
+
+func (q SortByDistance) Run(s *Store) ([]ConvertedLocation, error) {
+	results, err := q.Query.Run(s)
+	if err != nil {
+		return nil, err
+	}
+	type withDistance struct {
+		loc ConvertedLocation
+		d   float64
+	}
+	withDist := make([]withDistance, len(results))
+	for i, loc := range results {
+		d, err := geodist.VincentyDistance(q.Center, geodist.Point{Lat: loc.Latitude, Long: loc.Longitude})
+		if err != nil {
+			return nil, fmt.Errorf("error computing distance: %s", err)
+		}
+		withDist[i] = withDistance{loc, d}
+	}
+	sort.Slice(withDist, func(i, j int) bool {
+		return withDist[i].d < withDist[j].d
+	})
+	sorted := make([]ConvertedLocation, len(withDist))
+	for i, wd := range withDist {
+		sorted[i] = wd.loc
+	}
+	return sorted, nil
+}
+
+// locationKey identifies a point for set operations (AndQuery/OrQuery)
+// across independent query results.
+type locationKey struct {
+	lat, long float64
+	t         int64
+}
+
+func keyFor(loc ConvertedLocation) locationKey {
+	return locationKey{lat: loc.Latitude, long: loc.Longitude, t: loc.Time.UnixNano()}
+}
@@ -0,0 +1,62 @@
+package locationhistory
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+)
+
+// Store is an indexed, queryable collection of ConvertedLocation points.
+// Each point is encoded once, at construction time, into a geohash-prefix
+// term index (see geohash.go) so that repeated BoundingBoxQuery/
+// DistanceQuery calls don't have to rescan the full history. The index
+// holds no time terms: TimeRangeQuery scans every point. A Store can be persisted with Save and reopened with Open so
+// that the (often much larger) raw Takeout JSON only has to be parsed
+// once.
+type Store struct {
+	points []ConvertedLocation
+	index  *geoIndex
+}
+
+// NewStore builds an indexed Store from already-converted points.
+func NewStore(points []ConvertedLocation) *Store {
+	return &Store{
+		points: points,
+		index:  newGeoIndex(points),
+	}
+}
+
+// Points returns the full, unfiltered set of points backing the Store, in
+// their original order.
+func (s *Store) Points() []ConvertedLocation {
+	return s.points
+}
+
+// Open reopens a Store previously written by Save, avoiding a reparse of
+// the original Takeout export.
+func Open(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var points []ConvertedLocation
+	if err := gob.NewDecoder(f).Decode(&points); err != nil {
+		return nil, fmt.Errorf("error decoding store file: %s", err)
+	}
+	return NewStore(points), nil
+}
+
+// Save persists the Store's points so a later Open can skip re-parsing the
+// original Takeout export.
+func (s *Store) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating store file: %s", err)
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(s.points); err != nil {
+		return fmt.Errorf("error writing store file: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Package locationhistory indexes points exported from Google Location
+// History / Takeout dumps and answers bounding-box and radius queries
+// against them without rescanning the full history on every call.
+// Time-range queries are not indexed and scan every point.
+package locationhistory
+
+import "time"
+
+// Location is a single pinpoint as recorded in the legacy Takeout
+// "Location History.json" export.
+type Location struct {
+	Latitude  int64  `json:"latitudeE7"`
+	Longitude int64  `json:"longitudeE7"`
+	Timestamp string `json:"timestampMs"`
+}
+
+// LocationHistory is the top-level object of the legacy Takeout export.
+type LocationHistory struct {
+	Pinpoints []*Location `json:"locations"`
+}
+
+// ConvertedLocation is a pinpoint normalized to plain floating-point
+// coordinates and a time.Time, regardless of which Takeout format it was
+// parsed from.
+type ConvertedLocation struct {
+	Latitude  float64
+	Longitude float64
+	Time      time.Time
+}
@@ -0,0 +1,110 @@
+package locationhistory
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asmarques/geodist"
+)
+
+// Visit is a detected stay near a location, produced by DetectVisits.
+type Visit struct {
+	Start       time.Time
+	End         time.Time
+	Center      geodist.Point
+	NumPoints   int
+	MaxDistance float64 // km, furthest any point in the visit got from Center
+}
+
+// VisitDetectionOptions configures DetectVisits.
+type VisitDetectionOptions struct {
+	// MaxGap is the longest a run of points outside Radius (but within
+	// LoiterRadius) can last before the visit is considered over.
+	MaxGap time.Duration
+	// MinDwell is the minimum total duration a visit must span to be
+	// reported, filtering out brief passes-through.
+	MinDwell time.Duration
+	// LoiterRadius is a larger radius, in kilometers, within which a point
+	// outside Radius still counts as part of the same visit rather than
+	// ending it -- e.g. 3x Radius, to tolerate GPS jitter near the edge of
+	// the target location without breaking the visit into fragments.
+	LoiterRadius float64
+}
+
+// DefaultVisitDetectionOptions returns the package's suggested defaults: a
+// 15 minute max gap and a 10 minute minimum dwell time.
+func DefaultVisitDetectionOptions() VisitDetectionOptions {
+	return VisitDetectionOptions{
+		MaxGap:   15 * time.Minute,
+		MinDwell: 10 * time.Minute,
+	}
+}
+
+// candidate is a visit still being built by DetectVisits.
+type candidate struct {
+	start, end time.Time
+	numPoints  int
+	maxDist    float64
+}
+
+// DetectVisits finds stay points near center: runs of points within radius
+// kilometers of it, merged across brief excursions that stay within
+// opts.LoiterRadius for no longer than opts.MaxGap. points must be in
+// chronological order and include the points beyond opts.LoiterRadius of
+// center: such a point closes the open visit on the spot, so a history
+// narrowed to the loiter radius beforehand merges visits across longer
+// excursions.
+func DetectVisits(points []ConvertedLocation, center geodist.Point, radius float64, opts VisitDetectionOptions) ([]Visit, error) {
+	var visits []Visit
+	var cur *candidate
+	var gapStart time.Time
+	inGap := false
+
+	closeCandidate := func() {
+		if cur == nil {
+			return
+		}
+		if cur.end.Sub(cur.start) >= opts.MinDwell {
+			visits = append(visits, Visit{
+				Start:       cur.start,
+				End:         cur.end,
+				Center:      center,
+				NumPoints:   cur.numPoints,
+				MaxDistance: cur.maxDist,
+			})
+		}
+		cur = nil
+		inGap = false
+	}
+
+	for _, p := range points {
+		d, err := geodist.VincentyDistance(center, geodist.Point{Lat: p.Latitude, Long: p.Longitude})
+		if err != nil {
+			return nil, fmt.Errorf("error computing distance: %s", err)
+		}
+		switch {
+		case d <= radius:
+			if cur == nil {
+				cur = &candidate{start: p.Time}
+			}
+			cur.end = p.Time
+			cur.numPoints++
+			if d > cur.maxDist {
+				cur.maxDist = d
+			}
+			inGap = false
+		case cur != nil && d <= opts.LoiterRadius:
+			if !inGap {
+				gapStart = p.Time
+				inGap = true
+			}
+			if p.Time.Sub(gapStart) > opts.MaxGap {
+				closeCandidate()
+			}
+		default:
+			closeCandidate()
+		}
+	}
+	closeCandidate()
+	return visits, nil
+}
@@ -0,0 +1,146 @@
+package locationhistory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/asmarques/geodist"
+)
+
+var visitTestCenter = geodist.Point{Lat: 37.422, Long: -122.084}
+
+func minutesAfter(base time.Time, m int) time.Time {
+	return base.Add(time.Duration(m) * time.Minute)
+}
+
+// near and far are points roughly 0km and roughly 5km from visitTestCenter,
+// respectively -- well inside and well outside a radius of 1km.
+func near(t time.Time) ConvertedLocation {
+	return ConvertedLocation{Latitude: visitTestCenter.Lat, Longitude: visitTestCenter.Long, Time: t}
+}
+
+func far(t time.Time) ConvertedLocation {
+	return ConvertedLocation{Latitude: visitTestCenter.Lat + 0.05, Longitude: visitTestCenter.Long, Time: t}
+}
+
+func TestDetectVisitsBasicVisit(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []ConvertedLocation{
+		near(minutesAfter(base, 0)),
+		near(minutesAfter(base, 5)),
+		near(minutesAfter(base, 10)),
+		near(minutesAfter(base, 15)),
+	}
+	opts := VisitDetectionOptions{MaxGap: 15 * time.Minute, MinDwell: 10 * time.Minute, LoiterRadius: 1}
+	visits, err := DetectVisits(points, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 1 {
+		t.Fatalf("DetectVisits() = %d visits, want 1", len(visits))
+	}
+	v := visits[0]
+	if !v.Start.Equal(points[0].Time) || !v.End.Equal(points[len(points)-1].Time) {
+		t.Errorf("visit spans %s - %s, want %s - %s", v.Start, v.End, points[0].Time, points[len(points)-1].Time)
+	}
+	if v.NumPoints != len(points) {
+		t.Errorf("visit NumPoints = %d, want %d", v.NumPoints, len(points))
+	}
+}
+
+func TestDetectVisitsBelowMinDwellIsDropped(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []ConvertedLocation{
+		near(minutesAfter(base, 0)),
+		near(minutesAfter(base, 5)),
+	}
+	opts := VisitDetectionOptions{MaxGap: 15 * time.Minute, MinDwell: 10 * time.Minute, LoiterRadius: 1}
+	visits, err := DetectVisits(points, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 0 {
+		t.Fatalf("DetectVisits() = %d visits, want 0 (below MinDwell)", len(visits))
+	}
+}
+
+func TestDetectVisitsMergesShortExcursionWithinLoiterRadius(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []ConvertedLocation{
+		near(minutesAfter(base, 0)),
+		far(minutesAfter(base, 5)), // brief excursion, within LoiterRadius
+		near(minutesAfter(base, 10)),
+		near(minutesAfter(base, 20)),
+	}
+	opts := VisitDetectionOptions{MaxGap: 15 * time.Minute, MinDwell: 10 * time.Minute, LoiterRadius: 10}
+	visits, err := DetectVisits(points, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 1 {
+		t.Fatalf("DetectVisits() = %d visits, want 1 merged visit", len(visits))
+	}
+	if !visits[0].Start.Equal(points[0].Time) || !visits[0].End.Equal(points[len(points)-1].Time) {
+		t.Errorf("merged visit spans %s - %s, want %s - %s", visits[0].Start, visits[0].End, points[0].Time, points[len(points)-1].Time)
+	}
+}
+
+func TestDetectVisitsSplitsOnGapLongerThanMaxGap(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []ConvertedLocation{
+		near(minutesAfter(base, 0)),
+		near(minutesAfter(base, 10)),
+		far(minutesAfter(base, 20)), // gap starts
+		far(minutesAfter(base, 40)), // 20min since gap start, > 15min MaxGap: closes the visit
+		near(minutesAfter(base, 50)),
+		near(minutesAfter(base, 60)),
+	}
+	opts := VisitDetectionOptions{MaxGap: 15 * time.Minute, MinDwell: 10 * time.Minute, LoiterRadius: 10}
+	visits, err := DetectVisits(points, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 2 {
+		t.Fatalf("DetectVisits() = %d visits, want 2 (split by a gap longer than MaxGap)", len(visits))
+	}
+	if !visits[0].End.Equal(points[1].Time) {
+		t.Errorf("first visit ends at %s, want %s", visits[0].End, points[1].Time)
+	}
+	if !visits[1].Start.Equal(points[4].Time) {
+		t.Errorf("second visit starts at %s, want %s", visits[1].Start, points[4].Time)
+	}
+}
+
+func TestDetectVisitsExcursionOutsideLoiterRadiusEndsVisitImmediately(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []ConvertedLocation{
+		near(minutesAfter(base, 0)),
+		near(minutesAfter(base, 10)),
+		// far() is ~5.5km away, outside a LoiterRadius of 1km: this should
+		// close the candidate on the spot, not wait for MaxGap to elapse.
+		far(minutesAfter(base, 11)),
+		near(minutesAfter(base, 12)),
+	}
+	opts := VisitDetectionOptions{MaxGap: 15 * time.Minute, MinDwell: 1 * time.Minute, LoiterRadius: 1}
+	visits, err := DetectVisits(points, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 1 {
+		t.Fatalf("DetectVisits() = %d visits, want 1 (the second near point is too short-lived to meet MinDwell alone)", len(visits))
+	}
+	if !visits[0].End.Equal(points[1].Time) {
+		t.Errorf("first visit ends at %s, want %s", visits[0].End, points[1].Time)
+	}
+}
+
+func TestDetectVisitsEmptyInput(t *testing.T) {
+	opts := DefaultVisitDetectionOptions()
+	visits, err := DetectVisits(nil, visitTestCenter, 1, opts)
+	if err != nil {
+		t.Fatalf("DetectVisits returned error: %s", err)
+	}
+	if len(visits) != 0 {
+		t.Errorf("DetectVisits(nil, ...) = %d visits, want 0", len(visits))
+	}
+}